@@ -0,0 +1,160 @@
+package ocicni
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containernetworking/cni/libcni"
+	cnitypes "github.com/containernetworking/cni/pkg/types"
+	cnicurrent "github.com/containernetworking/cni/pkg/types/current"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultCacheDir is where ocicni persists enough information about each
+// successful ADD to later re-issue CHECK/DEL for the same attachment even
+// if the original PodNetwork is no longer fully known. It is deliberately
+// outside pluginDir: pluginDir is watched for config changes for the life
+// of the plugin, and cache writes on every SetUpPod/TearDownPod would
+// otherwise trigger a spurious config reload on every pod operation. Pass
+// WithCacheDir to relocate it, e.g. if DefaultCacheDir isn't writable.
+const DefaultCacheDir = "/var/lib/cni/cache"
+
+// cachedResult is what gets persisted to cacheDir for each successful
+// network attachment.
+type cachedResult struct {
+	Kind        string              `json:"kind"`
+	NetName     string              `json:"networkName"`
+	RuntimeConf *libcni.RuntimeConf `json:"runtimeConfig"`
+	Result      *cnicurrent.Result  `json:"result"`
+}
+
+func (plugin *cniNetworkPlugin) cacheDirFor(containerID string) string {
+	return filepath.Join(plugin.cacheDir, containerID)
+}
+
+func (plugin *cniNetworkPlugin) cacheFilePath(containerID, netName, ifName string) string {
+	return filepath.Join(plugin.cacheDirFor(containerID), netName+"-"+ifName+".json")
+}
+
+// cacheAdd persists rt and the CNI result returned for an ADD so that a
+// later CHECK or DEL can be issued with exactly the same arguments even
+// without the caller's original PodNetwork.
+func (plugin *cniNetworkPlugin) cacheAdd(rt *libcni.RuntimeConf, netName string, result cnitypes.Result) error {
+	current, err := cnicurrent.NewResultFromResult(result)
+	if err != nil {
+		return fmt.Errorf("failed to convert result to cache: %v", err)
+	}
+
+	cached := &cachedResult{
+		Kind:        CNIPluginName,
+		NetName:     netName,
+		RuntimeConf: rt,
+		Result:      current,
+	}
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cached result: %v", err)
+	}
+
+	fp := plugin.cacheFilePath(rt.ContainerID, netName, rt.IfName)
+	if err := os.MkdirAll(filepath.Dir(fp), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fp, data, 0600)
+}
+
+// cacheGet loads a previously-cached result for the given attachment, if
+// any exists.
+func (plugin *cniNetworkPlugin) cacheGet(containerID, netName, ifName string) (*cachedResult, error) {
+	data, err := ioutil.ReadFile(plugin.cacheFilePath(containerID, netName, ifName))
+	if err != nil {
+		return nil, err
+	}
+	cached := &cachedResult{}
+	if err := json.Unmarshal(data, cached); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cached result: %v", err)
+	}
+	return cached, nil
+}
+
+// cacheDel removes a cached attachment; called once its DEL has completed.
+func (plugin *cniNetworkPlugin) cacheDel(containerID, netName, ifName string) {
+	fp := plugin.cacheFilePath(containerID, netName, ifName)
+	if err := os.Remove(fp); err != nil && !os.IsNotExist(err) {
+		logrus.Warningf("Failed to remove cached CNI result %s: %v", fp, err)
+		return
+	}
+	// Best-effort cleanup of the now-possibly-empty per-container directory
+	os.Remove(plugin.cacheDirFor(containerID))
+}
+
+// GC scans the cache directory for attachments belonging to containers that
+// are not in activePods and issues a CNI DEL for each of them, removing
+// their cache entries. This cleans up network state left behind by pods
+// whose TearDownPod was never called, e.g. because the runtime was
+// restarted or killed mid-operation. An entry whose network is no longer
+// loaded is dropped without a DEL; an entry whose DEL fails is kept and
+// its error is included in the returned error.
+func (plugin *cniNetworkPlugin) GC(activePods []PodNetwork) error {
+	active := make(map[string]bool)
+	for _, podNetwork := range activePods {
+		active[podNetwork.ID] = true
+	}
+
+	containerDirs, err := ioutil.ReadDir(plugin.cacheDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var errs []string
+	for _, containerDir := range containerDirs {
+		containerID := containerDir.Name()
+		if active[containerID] {
+			continue
+		}
+
+		entries, err := ioutil.ReadDir(plugin.cacheDirFor(containerID))
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", containerID, err))
+			continue
+		}
+		for _, entry := range entries {
+			data, err := ioutil.ReadFile(filepath.Join(plugin.cacheDirFor(containerID), entry.Name()))
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s/%s: %v", containerID, entry.Name(), err))
+				continue
+			}
+			cached := &cachedResult{}
+			if err := json.Unmarshal(data, cached); err != nil {
+				errs = append(errs, fmt.Sprintf("%s/%s: %v", containerID, entry.Name(), err))
+				continue
+			}
+
+			network, err := plugin.getNetwork(cached.NetName)
+			if err != nil {
+				logrus.Warningf("GC: network %q for stale attachment %s/%s no longer exists, dropping cache entry", cached.NetName, containerID, cached.RuntimeConf.IfName)
+				plugin.cacheDel(containerID, cached.NetName, cached.RuntimeConf.IfName)
+				continue
+			}
+
+			logrus.Infof("GC: deleting stale CNI attachment %s/%s/%s", containerID, cached.NetName, cached.RuntimeConf.IfName)
+			if err := network.CNIConfig.DelNetworkList(network.NetworkConfig, cached.RuntimeConf); err != nil {
+				errs = append(errs, fmt.Sprintf("%s/%s: %v", containerID, cached.RuntimeConf.IfName, err))
+				continue
+			}
+			plugin.cacheDel(containerID, cached.NetName, cached.RuntimeConf.IfName)
+		}
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("GC failed for some attachments: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+// Package cniinvoke re-exports the CNI invoke.Exec interface so that
+// callers of ocicni can supply a custom executor (for example, a mock CNI
+// binary or an in-process delegate) without importing the upstream CNI
+// package directly.
+package cniinvoke
+
+import (
+	"github.com/containernetworking/cni/pkg/invoke"
+)
+
+// Exec is used by libcni to actually run (or otherwise invoke) CNI plugin
+// binaries. It is a type alias for invoke.Exec, so any implementation of
+// one satisfies the other.
+type Exec = invoke.Exec
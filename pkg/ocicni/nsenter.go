@@ -0,0 +1,82 @@
+package ocicni
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+)
+
+// getContainerDetails uses nsenter to enter the network namespace at
+// netnsPath and inspect the addresses assigned to ifName, returning the
+// first address matching addrType ("-4" or "-6") and the interface's MAC
+// address.
+func getContainerDetails(nsenterPath, netnsPath, ifName, addrType string) (*net.IPNet, net.HardwareAddr, error) {
+	args := []string{
+		"--net=" + netnsPath,
+		"-F", "--",
+		"ip", "-o", addrType, "addr", "show", "dev", ifName,
+	}
+	out, err := exec.Command(nsenterPath, args...).CombinedOutput()
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to obtain addresses for %s in %s: %v (%s)", ifName, netnsPath, err, string(out))
+	}
+
+	ip, err := parseIPFromIPAddrOutput(out)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mac, err := getMACFromNsenter(nsenterPath, netnsPath, ifName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ip, mac, nil
+}
+
+func parseIPFromIPAddrOutput(out []byte) (*net.IPNet, error) {
+	fields := strings.Fields(string(bytes.TrimSpace(out)))
+	for i, field := range fields {
+		if field == "inet" || field == "inet6" {
+			if i+1 >= len(fields) {
+				break
+			}
+			ip, ipNet, err := net.ParseCIDR(fields[i+1])
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse address %q: %v", fields[i+1], err)
+			}
+			ipNet.IP = ip
+			return ipNet, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to find address in output %q", string(out))
+}
+
+func getMACFromNsenter(nsenterPath, netnsPath, ifName string) (net.HardwareAddr, error) {
+	args := []string{
+		"--net=" + netnsPath,
+		"-F", "--",
+		"ip", "-o", "link", "show", "dev", ifName,
+	}
+	out, err := exec.Command(nsenterPath, args...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("unable to obtain link details for %s in %s: %v (%s)", ifName, netnsPath, err, string(out))
+	}
+
+	fields := strings.Fields(string(bytes.TrimSpace(out)))
+	for i, field := range fields {
+		if field == "link/ether" {
+			if i+1 >= len(fields) {
+				break
+			}
+			mac, err := net.ParseMAC(fields[i+1])
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse MAC %q: %v", fields[i+1], err)
+			}
+			return mac, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to find MAC address in output %q", string(out))
+}
@@ -15,21 +15,26 @@ import (
 	cnicurrent "github.com/containernetworking/cni/pkg/types/current"
 	"github.com/fsnotify/fsnotify"
 	"github.com/sirupsen/logrus"
+
+	"github.com/cri-o/ocicni/pkg/ocicni/cniinvoke"
 )
 
 type cniNetworkPlugin struct {
 	loNetwork *cniNetwork
 
 	sync.RWMutex
-	defaultNetName string
-	networks       map[string]*cniNetwork
+	defaultNetName     string
+	defaultNetSelector DefaultNetworkSelector
+	networks           map[string]*cniNetwork
+	networkInfos       []NetworkInfo
 
 	nsenterPath        string
 	pluginDir          string
+	cacheDir           string
 	cniDirs            []string
 	vendorCNIDirPrefix string
-
-	monitorNetDirChan chan struct{}
+	exec               cniinvoke.Exec
+	workerLimit        int
 
 	// The pod map provides synchronization for a given pod's network
 	// operations.  Each pod's setup/teardown/status operations
@@ -102,6 +107,10 @@ func (plugin *cniNetworkPlugin) podUnlock(podNetwork PodNetwork) {
 	}
 }
 
+// monitorNetDir watches pluginDir for the life of the plugin, re-syncing
+// plugin.networks (and re-evaluating the default network) on every config
+// file create, write, remove, or rename so that all of those are reflected
+// live rather than only until the first successful sync.
 func (plugin *cniNetworkPlugin) monitorNetDir() {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -110,51 +119,105 @@ func (plugin *cniNetworkPlugin) monitorNetDir() {
 	}
 	defer watcher.Close()
 
-	go func() {
-		for {
-			select {
-			case event := <-watcher.Events:
-				logrus.Debugf("CNI monitoring event %v", event)
-				if event.Op&fsnotify.Create != fsnotify.Create &&
-					event.Op&fsnotify.Write != fsnotify.Write {
-					continue
-				}
-
-				if err = plugin.syncNetworkConfig(); err != nil {
-					logrus.Errorf("CNI config loading failed, continue monitoring: %v", err)
-					continue
-				}
-
-				// Stop watching when we have a default network
-				if plugin.getDefaultNetwork() != nil {
-					logrus.Infof("Found CNI default network; stop watching")
-					close(plugin.monitorNetDirChan)
-					return
-				}
-
-			case err := <-watcher.Errors:
-				logrus.Errorf("CNI monitoring error %v", err)
-				close(plugin.monitorNetDirChan)
-				return
+	if err := watcher.Add(plugin.pluginDir); err != nil {
+		logrus.Error(err)
+		return
+	}
+
+	for {
+		select {
+		case event := <-watcher.Events:
+			logrus.Debugf("CNI monitoring event %v", event)
+			if err := plugin.syncNetworkConfig(); err != nil {
+				logrus.Errorf("CNI config loading failed, continue monitoring: %v", err)
 			}
+
+		case err := <-watcher.Errors:
+			logrus.Errorf("CNI monitoring error, continue monitoring: %v", err)
 		}
-	}()
+	}
+}
 
-	if err = watcher.Add(plugin.pluginDir); err != nil {
-		logrus.Error(err)
-		return
+// CNIOption configures optional behavior of the CNIPlugin returned by
+// InitCNI.
+type CNIOption func(*cniNetworkPlugin) error
+
+// WithCNIExec overrides the cniinvoke.Exec used to invoke CNI plugin
+// binaries. This lets callers supply a mock executor in tests (fake CNI
+// binaries, in-process delegates) without touching the filesystem. If not
+// given, libcni's default executor is used.
+func WithCNIExec(exec cniinvoke.Exec) CNIOption {
+	return func(plugin *cniNetworkPlugin) error {
+		plugin.exec = exec
+		return nil
+	}
+}
+
+// WithDefaultNetworkSelector overrides how the default network (used by
+// pods that don't request a specific network) is chosen among the networks
+// currently loaded from pluginDir. It is re-evaluated every time the
+// on-disk config set changes, and takes precedence over InitCNI's
+// defaultNetName argument.
+func WithDefaultNetworkSelector(selector DefaultNetworkSelector) CNIOption {
+	return func(plugin *cniNetworkPlugin) error {
+		plugin.defaultNetSelector = selector
+		return nil
 	}
+}
 
-	<-plugin.monitorNetDirChan
+// namedNetworkSelector returns a DefaultNetworkSelector that picks the
+// network called name once (and for as long as) it is present among the
+// loaded networks.
+func namedNetworkSelector(name string) DefaultNetworkSelector {
+	return func(networks []NetworkInfo) string {
+		for _, n := range networks {
+			if n.Name == name {
+				return n.Name
+			}
+		}
+		return ""
+	}
+}
+
+// firstNetworkSelector picks the first network in on-disk config file sort
+// order. This preserves ocicni's historical "first sorted file wins"
+// behavior for callers who don't care which network ends up the default.
+func firstNetworkSelector(networks []NetworkInfo) string {
+	if len(networks) == 0 {
+		return ""
+	}
+	return networks[0].Name
+}
+
+// WithWorkerLimit overrides how many of a pod's network attachments are set
+// up, torn down, or checked concurrently. If not given, or given a limit
+// <= 0, DefaultNetworkAttachmentWorkers is used.
+func WithWorkerLimit(limit int) CNIOption {
+	return func(plugin *cniNetworkPlugin) error {
+		plugin.workerLimit = limit
+		return nil
+	}
+}
+
+// WithCacheDir overrides where ocicni persists cached per-attachment CNI
+// results (see DefaultCacheDir). Callers in environments where
+// DefaultCacheDir isn't writable (e.g. rootless or minimal images) should
+// point this somewhere they can write.
+func WithCacheDir(cacheDir string) CNIOption {
+	return func(plugin *cniNetworkPlugin) error {
+		plugin.cacheDir = cacheDir
+		return nil
+	}
 }
 
 // InitCNI takes the plugin directory and CNI directories where the CNI config
 // files should be searched for.  If no valid CNI configs exist, network requests
 // will fail until valid CNI config files are present in the config directory.
-// If defaultNetName is not empty, a CNI config with that network name will
-// be used as the default CNI network, and container network operations will
-// fail until that network config is present and valid.
-func InitCNI(defaultNetName string, pluginDir string, cniDirs ...string) (CNIPlugin, error) {
+// If defaultNetName is not empty, the CNI network with that name is used as
+// the default network once it is present and valid; pass
+// WithDefaultNetworkSelector for more control over which network is
+// selected as networks come and go.
+func InitCNI(defaultNetName string, pluginDir string, cniDirs []string, options ...CNIOption) (CNIPlugin, error) {
 	vendorCNIDirPrefix := ""
 	if pluginDir == "" {
 		pluginDir = DefaultNetDir
@@ -163,20 +226,41 @@ func InitCNI(defaultNetName string, pluginDir string, cniDirs ...string) (CNIPlu
 		cniDirs = []string{DefaultCNIDir}
 	}
 	plugin := &cniNetworkPlugin{
-		defaultNetName:     defaultNetName,
 		networks:           make(map[string]*cniNetwork),
-		loNetwork:          getLoNetwork(cniDirs, vendorCNIDirPrefix),
 		pluginDir:          pluginDir,
+		cacheDir:           DefaultCacheDir,
 		cniDirs:            cniDirs,
 		vendorCNIDirPrefix: vendorCNIDirPrefix,
-		monitorNetDirChan:  make(chan struct{}),
 		pods:               make(map[string]*podLock),
 	}
 
-	var err error
-	plugin.nsenterPath, err = exec.LookPath("nsenter")
-	if err != nil {
-		return nil, err
+	for _, option := range options {
+		if err := option(plugin); err != nil {
+			return nil, err
+		}
+	}
+
+	if plugin.defaultNetSelector == nil {
+		if defaultNetName != "" {
+			plugin.defaultNetSelector = namedNetworkSelector(defaultNetName)
+		} else {
+			plugin.defaultNetSelector = firstNetworkSelector
+		}
+	}
+
+	if plugin.workerLimit <= 0 {
+		plugin.workerLimit = DefaultNetworkAttachmentWorkers
+	}
+
+	plugin.loNetwork = getLoNetwork(cniDirs, vendorCNIDirPrefix, plugin.exec)
+
+	// nsenter is only needed as a last-resort fallback in
+	// GetPodNetworkStatus, so its absence is not fatal: just remember that
+	// we don't have it and skip that fallback later.
+	if nsenterPath, err := exec.LookPath("nsenter"); err == nil {
+		plugin.nsenterPath = nsenterPath
+	} else {
+		logrus.Infof("nsenter not found, will be unable to determine pod network status without a cached or CHECK-able CNI result: %v", err)
 	}
 
 	// Fail loudly if plugin directory doesn't exist, because fsnotify watcher
@@ -185,27 +269,29 @@ func InitCNI(defaultNetName string, pluginDir string, cniDirs ...string) (CNIPlu
 		return nil, err
 	}
 
-	if err := plugin.syncNetworkConfig(); err != nil {
-		// We do not have a valid default network, so start the
-		// monitoring thread.  Network setup/teardown requests
-		// will fail until we have a valid default network.
-		go plugin.monitorNetDir()
-	}
+	// Ignore the error: if no valid CNI configs exist yet, network requests
+	// will simply fail until the watcher below sees one added.
+	_ = plugin.syncNetworkConfig()
+
+	// Keep watching pluginDir for the life of the plugin so that config
+	// additions, edits, and removals are always reflected, not just until
+	// the first successful sync.
+	go plugin.monitorNetDir()
 
 	return plugin, nil
 }
 
-func (plugin *cniNetworkPlugin) loadNetworks() (map[string]*cniNetwork, string, error) {
+func (plugin *cniNetworkPlugin) loadNetworks() (map[string]*cniNetwork, []NetworkInfo, error) {
 	files, err := libcni.ConfFiles(plugin.pluginDir, []string{".conf", ".conflist", ".json"})
 	switch {
 	case err != nil:
-		return nil, "", err
+		return nil, nil, err
 	case len(files) == 0:
-		return nil, "", errMissingDefaultNetwork
+		return nil, nil, errMissingDefaultNetwork
 	}
 
 	networks := make(map[string]*cniNetwork)
-	defaultNetName := ""
+	infos := make([]NetworkInfo, 0, len(files))
 
 	sort.Strings(files)
 	for _, confFile := range files {
@@ -245,6 +331,7 @@ func (plugin *cniNetworkPlugin) loadNetworks() (map[string]*cniNetwork, string,
 		// Search for vendor-specific plugins as well as default plugins in the CNI codebase.
 		cninet := &libcni.CNIConfig{
 			Path: plugin.cniDirs,
+			Exec: plugin.exec,
 		}
 		for _, p := range confList.Plugins {
 			vendorDir := vendorCNIDir(plugin.vendorCNIDirPrefix, p.Network.Type)
@@ -255,24 +342,25 @@ func (plugin *cniNetworkPlugin) loadNetworks() (map[string]*cniNetwork, string,
 			NetworkConfig: confList,
 			CNIConfig:     cninet,
 		}
-
-		if defaultNetName == "" {
-			defaultNetName = confList.Name
-		}
+		infos = append(infos, NetworkInfo{
+			Name: confList.Name,
+			Type: confList.Plugins[0].Network.Type,
+			File: confFile,
+		})
 	}
 
 	if len(networks) == 0 {
-		return nil, "", fmt.Errorf("No valid networks found in %s", plugin.pluginDir)
+		return nil, nil, fmt.Errorf("No valid networks found in %s", plugin.pluginDir)
 	}
 
-	return networks, defaultNetName, nil
+	return networks, infos, nil
 }
 
 func vendorCNIDir(prefix, pluginType string) string {
 	return fmt.Sprintf(VendorCNIDirTemplate, prefix, pluginType)
 }
 
-func getLoNetwork(cniDirs []string, vendorDirPrefix string) *cniNetwork {
+func getLoNetwork(cniDirs []string, vendorDirPrefix string, exec cniinvoke.Exec) *cniNetwork {
 	loConfig, err := libcni.ConfListFromBytes([]byte(`{
   "cniVersion": "0.2.0",
   "name": "cni-loopback",
@@ -288,6 +376,7 @@ func getLoNetwork(cniDirs []string, vendorDirPrefix string) *cniNetwork {
 	vendorDir := vendorCNIDir(vendorDirPrefix, loConfig.Plugins[0].Network.Type)
 	cninet := &libcni.CNIConfig{
 		Path: append(cniDirs, vendorDir),
+		Exec: exec,
 	}
 	loNetwork := &cniNetwork{
 		name:          "lo",
@@ -298,8 +387,15 @@ func getLoNetwork(cniDirs []string, vendorDirPrefix string) *cniNetwork {
 	return loNetwork
 }
 
+// syncNetworkConfig reloads the CNI config files under pluginDir and
+// re-evaluates the default network from the result, so that it always
+// reflects the current on-disk config set rather than latching onto
+// whichever network was found first. On error, the last-good networks,
+// infos, and default network name are left untouched so a transient bad
+// sync (e.g. an editor momentarily leaving the sole config invalid)
+// doesn't make already-running pods fail.
 func (plugin *cniNetworkPlugin) syncNetworkConfig() error {
-	networks, defaultNetName, err := plugin.loadNetworks()
+	networks, infos, err := plugin.loadNetworks()
 	if err != nil {
 		logrus.Errorf("Error loading CNI networks: %s", err)
 		return err
@@ -307,11 +403,9 @@ func (plugin *cniNetworkPlugin) syncNetworkConfig() error {
 
 	plugin.Lock()
 	defer plugin.Unlock()
-	if plugin.defaultNetName == "" {
-		plugin.defaultNetName = defaultNetName
-	}
 	plugin.networks = networks
-
+	plugin.networkInfos = infos
+	plugin.defaultNetName = plugin.defaultNetSelector(infos)
 	return nil
 }
 
@@ -331,6 +425,18 @@ func (plugin *cniNetworkPlugin) getDefaultNetworkName() string {
 	return plugin.defaultNetName
 }
 
+// NetworkList returns the names of all CNI networks currently loaded from
+// pluginDir, in on-disk config file sort order.
+func (plugin *cniNetworkPlugin) NetworkList() []string {
+	plugin.RLock()
+	defer plugin.RUnlock()
+	names := make([]string, 0, len(plugin.networkInfos))
+	for _, info := range plugin.networkInfos {
+		names = append(names, info.Name)
+	}
+	return names
+}
+
 func (plugin *cniNetworkPlugin) getDefaultNetwork() *cniNetwork {
 	defaultNetName := plugin.getDefaultNetworkName()
 	if defaultNetName == "" {
@@ -351,24 +457,104 @@ func (plugin *cniNetworkPlugin) Name() string {
 	return CNIPluginName
 }
 
-func (plugin *cniNetworkPlugin) forEachNetwork(podNetwork PodNetwork, forEachFunc func(*cniNetwork, string, PodNetwork) error) error {
-	networks := podNetwork.Networks
-	if len(networks) == 0 {
-		networks = append(networks, plugin.getDefaultNetworkName())
+// networkAttachmentJob is a single network attachment with its interface
+// name and target network already resolved, ready to be set up, torn down,
+// or checked.
+type networkAttachmentJob struct {
+	network    *cniNetwork
+	ifName     string
+	attachment NetAttachment
+}
+
+// resolveAttachments figures out, for every network attachment requested by
+// podNetwork (or the default network, if none were requested), which CNI
+// network it targets and what interface name it gets: the caller's
+// requested Ifname if given, or the next unused "eth%d" otherwise. This is
+// done up front and sequentially so that interface name assignment is
+// deterministic before any attachment is processed concurrently.
+func (plugin *cniNetworkPlugin) resolveAttachments(podNetwork PodNetwork) ([]networkAttachmentJob, error) {
+	attachments := podNetwork.Networks
+	if len(attachments) == 0 {
+		attachments = []NetAttachment{{Name: plugin.getDefaultNetworkName()}}
+	}
+
+	usedIfNames := make(map[string]bool)
+	for _, attachment := range attachments {
+		if attachment.Ifname != "" {
+			usedIfNames[attachment.Ifname] = true
+		}
 	}
-	for i, netName := range networks {
-		// Interface names start at "eth0" and count up for each network
-		ifName := fmt.Sprintf("eth%d", i)
+
+	jobs := make([]networkAttachmentJob, 0, len(attachments))
+	nextEth := 0
+	for _, attachment := range attachments {
+		netName := attachment.Name
+		if netName == "" {
+			netName = plugin.getDefaultNetworkName()
+		}
+
+		ifName := attachment.Ifname
+		for ifName == "" {
+			candidate := fmt.Sprintf("eth%d", nextEth)
+			nextEth++
+			if !usedIfNames[candidate] {
+				ifName = candidate
+				usedIfNames[candidate] = true
+			}
+		}
+
 		network, err := plugin.getNetwork(netName)
 		if err != nil {
 			logrus.Errorf(err.Error())
-			return err
+			return nil, err
 		}
-		if err := forEachFunc(network, ifName, podNetwork); err != nil {
-			return err
+		jobs = append(jobs, networkAttachmentJob{network: network, ifName: ifName, attachment: attachment})
+	}
+	return jobs, nil
+}
+
+// runOnAttachments runs fn once for every job in jobs, with up to
+// plugin.workerLimit of them running concurrently, and always waits for
+// every job to finish (even after some have failed) before returning. The
+// returned slice has one entry per job, in the same order as jobs, holding
+// that job's error (or nil on success).
+func (plugin *cniNetworkPlugin) runOnAttachments(jobs []networkAttachmentJob, fn func(i int, job networkAttachmentJob) error) []error {
+	errs := make([]error, len(jobs))
+
+	limit := plugin.workerLimit
+	if limit <= 0 || limit > len(jobs) {
+		limit = len(jobs)
+	}
+
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i, job := range jobs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, job networkAttachmentJob) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = fn(i, job)
+		}(i, job)
+	}
+	wg.Wait()
+
+	return errs
+}
+
+// aggregateErrors combines the non-nil errors in errs into a single error,
+// or returns nil if there aren't any.
+func aggregateErrors(errs []error) error {
+	var msgs []string
+	for _, err := range errs {
+		if err != nil {
+			msgs = append(msgs, err.Error())
 		}
 	}
-	return nil
+	if len(msgs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(msgs, "; "))
 }
 
 func (plugin *cniNetworkPlugin) SetUpPod(podNetwork PodNetwork) ([]cnitypes.Result, error) {
@@ -379,22 +565,52 @@ func (plugin *cniNetworkPlugin) SetUpPod(podNetwork PodNetwork) ([]cnitypes.Resu
 	plugin.podLock(podNetwork).Lock()
 	defer plugin.podUnlock(podNetwork)
 
-	_, err := plugin.loNetwork.addToNetwork(podNetwork, "lo")
+	loRt, err := buildCNIRuntimeConf(podNetwork, plugin.loNetwork.name, NetAttachment{}, "lo")
 	if err != nil {
+		return nil, err
+	}
+	if _, err := plugin.loNetwork.addToNetwork(loRt); err != nil {
 		logrus.Errorf("Error while adding to cni lo network: %s", err)
 		return nil, err
 	}
 
-	results := make([]cnitypes.Result, 0)
-	if err := plugin.forEachNetwork(podNetwork, func(network *cniNetwork, ifName string, podNetwork PodNetwork) error {
-		result, err := network.addToNetwork(podNetwork, ifName)
+	jobs, err := plugin.resolveAttachments(podNetwork)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]cnitypes.Result, len(jobs))
+	rts := make([]*libcni.RuntimeConf, len(jobs))
+	errs := plugin.runOnAttachments(jobs, func(i int, job networkAttachmentJob) error {
+		rt, err := buildCNIRuntimeConf(podNetwork, job.network.name, job.attachment, job.ifName)
 		if err != nil {
-			logrus.Errorf("Error while adding pod to CNI network %q: %s", network.name, err)
 			return err
 		}
-		results = append(results, result)
+		result, err := job.network.addToNetwork(rt)
+		if err != nil {
+			logrus.Errorf("Error while adding pod to CNI network %q: %s", job.network.name, err)
+			return err
+		}
+		if err := plugin.cacheAdd(rt, job.network.name, result); err != nil {
+			logrus.Warningf("Failed to cache CNI result for network %q: %v", job.network.name, err)
+		}
+		results[i] = result
+		rts[i] = rt
 		return nil
-	}); err != nil {
+	})
+
+	if err := aggregateErrors(errs); err != nil {
+		// Some attachments succeeded before another failed; roll those back
+		// so callers don't have to clean up a partially-configured pod.
+		for i, job := range jobs {
+			if rts[i] == nil {
+				continue
+			}
+			if delErr := job.network.deleteFromNetwork(rts[i]); delErr != nil {
+				logrus.Errorf("Error rolling back CNI network %q after failed pod setup: %v", job.network.name, delErr)
+			}
+			plugin.cacheDel(podNetwork.ID, job.network.name, job.ifName)
+		}
 		return nil, err
 	}
 
@@ -409,13 +625,70 @@ func (plugin *cniNetworkPlugin) TearDownPod(podNetwork PodNetwork) error {
 	plugin.podLock(podNetwork).Lock()
 	defer plugin.podUnlock(podNetwork)
 
-	return plugin.forEachNetwork(podNetwork, func(network *cniNetwork, ifName string, podNetwork PodNetwork) error {
-		if err := network.deleteFromNetwork(podNetwork, ifName); err != nil {
-			logrus.Errorf("Error while removing pod from CNI network %q: %s", network.name, err)
+	jobs, err := plugin.resolveAttachments(podNetwork)
+	if err != nil {
+		return err
+	}
+
+	// Attempt every deletion, even if one of them fails, so a single broken
+	// attachment doesn't leave the others dangling.
+	errs := plugin.runOnAttachments(jobs, func(i int, job networkAttachmentJob) error {
+		rt := plugin.runtimeConfForTeardown(podNetwork, job.network.name, job.attachment, job.ifName)
+		if err := job.network.deleteFromNetwork(rt); err != nil {
+			logrus.Errorf("Error while removing pod from CNI network %q: %s", job.network.name, err)
 			return err
 		}
+		plugin.cacheDel(podNetwork.ID, job.network.name, job.ifName)
 		return nil
 	})
+
+	return aggregateErrors(errs)
+}
+
+// CheckPod verifies that a pod's existing network attachments are still
+// correctly configured, using libcni's CHECK operation. It prefers the
+// RuntimeConf that was cached at SetUpPod time so that CHECK is issued with
+// exactly the arguments used for the original ADD.
+func (plugin *cniNetworkPlugin) CheckPod(podNetwork PodNetwork) error {
+	if err := plugin.checkInitialized(podNetwork); err != nil {
+		return err
+	}
+
+	plugin.podLock(podNetwork).Lock()
+	defer plugin.podUnlock(podNetwork)
+
+	jobs, err := plugin.resolveAttachments(podNetwork)
+	if err != nil {
+		return err
+	}
+
+	errs := plugin.runOnAttachments(jobs, func(i int, job networkAttachmentJob) error {
+		rt := plugin.runtimeConfForTeardown(podNetwork, job.network.name, job.attachment, job.ifName)
+		if err := job.network.checkNetwork(rt); err != nil {
+			logrus.Errorf("Error while checking pod's CNI network %q: %s", job.network.name, err)
+			return err
+		}
+		return nil
+	})
+
+	return aggregateErrors(errs)
+}
+
+// runtimeConfForTeardown returns the libcni.RuntimeConf that was cached for
+// a prior SetUpPod of this attachment, if one exists; otherwise it builds a
+// fresh one from the (possibly partial) podNetwork supplied by the caller.
+func (plugin *cniNetworkPlugin) runtimeConfForTeardown(podNetwork PodNetwork, netName string, attachment NetAttachment, ifName string) *libcni.RuntimeConf {
+	if cached, err := plugin.cacheGet(podNetwork.ID, netName, ifName); err == nil {
+		return cached.RuntimeConf
+	}
+	rt, err := buildCNIRuntimeConf(podNetwork, netName, attachment, ifName)
+	if err != nil {
+		// buildCNIRuntimeConf never actually returns an error today, but
+		// fall back to a bare RuntimeConf rather than panic if that changes.
+		logrus.Warningf("Failed to build CNI runtime config for %q: %v", netName, err)
+		return &libcni.RuntimeConf{ContainerID: podNetwork.ID, NetNS: podNetwork.NetNS, IfName: ifName}
+	}
+	return rt
 }
 
 // GetPodNetworkStatus returns IP addressing and interface details for all
@@ -424,46 +697,74 @@ func (plugin *cniNetworkPlugin) GetPodNetworkStatus(podNetwork PodNetwork) ([]cn
 	plugin.podLock(podNetwork).Lock()
 	defer plugin.podUnlock(podNetwork)
 
-	results := make([]cnitypes.Result, 0)
-	if err := plugin.forEachNetwork(podNetwork, func(network *cniNetwork, ifName string, podNetwork PodNetwork) error {
-		ip, mac, err := getContainerDetails(plugin.nsenterPath, podNetwork.NetNS, ifName, "-4")
+	jobs, err := plugin.resolveAttachments(podNetwork)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]cnitypes.Result, len(jobs))
+	errs := plugin.runOnAttachments(jobs, func(i int, job networkAttachmentJob) error {
+		result, err := plugin.getNetworkStatus(job.network, job.ifName, job.attachment, podNetwork)
 		if err != nil {
 			return err
 		}
-
-		// Until CNI's GET request lands, construct the Result manually
-		results = append(results, &cnicurrent.Result{
-			CNIVersion: "0.3.1",
-			Interfaces: []*cnicurrent.Interface{
-				{
-					Name:    ifName,
-					Mac:     mac.String(),
-					Sandbox: podNetwork.NetNS,
-				},
-			},
-			IPs: []*cnicurrent.IPConfig{
-				{
-					Version:   "4",
-					Interface: cnicurrent.Int(0),
-					Address:   *ip,
-				},
-			},
-		})
+		results[i] = result
 		return nil
-	}); err != nil {
+	})
+
+	if err := aggregateErrors(errs); err != nil {
 		return nil, err
 	}
-
 	return results, nil
 }
 
-func (network *cniNetwork) addToNetwork(podNetwork PodNetwork, ifName string) (cnitypes.Result, error) {
-	rt, err := buildCNIRuntimeConf(podNetwork, ifName)
+// getNetworkStatus returns the current CNI result for a single attachment.
+// It prefers the result cached at SetUpPod time; failing that, it issues a
+// CHECK purely to log whether the attachment still looks healthy, then
+// falls back to introspecting the namespace directly for addressing (if
+// nsenter is available) regardless of whether CHECK succeeded, since CHECK
+// itself carries no addressing information.
+func (plugin *cniNetworkPlugin) getNetworkStatus(network *cniNetwork, ifName string, attachment NetAttachment, podNetwork PodNetwork) (cnitypes.Result, error) {
+	if cached, err := plugin.cacheGet(podNetwork.ID, network.name, ifName); err == nil {
+		return cached.Result, nil
+	}
+
+	rt, err := buildCNIRuntimeConf(podNetwork, network.name, attachment, ifName)
 	if err != nil {
-		logrus.Errorf("Error adding network: %v", err)
 		return nil, err
 	}
+	if err := network.checkNetwork(rt); err != nil {
+		logrus.Warningf("CHECK unavailable for network %q, falling back to netns introspection: %v", network.name, err)
+	}
 
+	if plugin.nsenterPath == "" {
+		return nil, fmt.Errorf("no cached result for network %q and nsenter is unavailable to determine addressing", network.name)
+	}
+
+	ip, mac, err := getContainerDetails(plugin.nsenterPath, podNetwork.NetNS, ifName, "-4")
+	if err != nil {
+		return nil, err
+	}
+	return &cnicurrent.Result{
+		CNIVersion: "0.3.1",
+		Interfaces: []*cnicurrent.Interface{
+			{
+				Name:    ifName,
+				Mac:     mac.String(),
+				Sandbox: podNetwork.NetNS,
+			},
+		},
+		IPs: []*cnicurrent.IPConfig{
+			{
+				Version:   "4",
+				Interface: cnicurrent.Int(0),
+				Address:   *ip,
+			},
+		},
+	}, nil
+}
+
+func (network *cniNetwork) addToNetwork(rt *libcni.RuntimeConf) (cnitypes.Result, error) {
 	netconf, cninet := network.NetworkConfig, network.CNIConfig
 	logrus.Infof("About to add CNI network %s (type=%v)", netconf.Name, netconf.Plugins[0].Network.Type)
 	res, err := cninet.AddNetworkList(netconf, rt)
@@ -475,24 +776,31 @@ func (network *cniNetwork) addToNetwork(podNetwork PodNetwork, ifName string) (c
 	return res, nil
 }
 
-func (network *cniNetwork) deleteFromNetwork(podNetwork PodNetwork, ifName string) error {
-	rt, err := buildCNIRuntimeConf(podNetwork, ifName)
-	if err != nil {
+func (network *cniNetwork) deleteFromNetwork(rt *libcni.RuntimeConf) error {
+	netconf, cninet := network.NetworkConfig, network.CNIConfig
+	logrus.Infof("About to del CNI network %s (type=%v)", netconf.Name, netconf.Plugins[0].Network.Type)
+	if err := cninet.DelNetworkList(netconf, rt); err != nil {
 		logrus.Errorf("Error deleting network: %v", err)
 		return err
 	}
+	return nil
+}
 
+func (network *cniNetwork) checkNetwork(rt *libcni.RuntimeConf) error {
 	netconf, cninet := network.NetworkConfig, network.CNIConfig
-	logrus.Infof("About to del CNI network %s (type=%v)", netconf.Name, netconf.Plugins[0].Network.Type)
-	err = cninet.DelNetworkList(netconf, rt)
-	if err != nil {
-		logrus.Errorf("Error deleting network: %v", err)
+	logrus.Infof("About to check CNI network %s (type=%v)", netconf.Name, netconf.Plugins[0].Network.Type)
+	if err := cninet.CheckNetworkList(netconf, rt); err != nil {
+		logrus.Errorf("Error checking network: %v", err)
 		return err
 	}
 	return nil
 }
 
-func buildCNIRuntimeConf(podNetwork PodNetwork, ifName string) (*libcni.RuntimeConf, error) {
+// buildCNIRuntimeConf builds the libcni.RuntimeConf for a single network
+// attachment, translating the pod's port mappings and the attachment's
+// static IPs/MAC/aliases/bandwidth/DNS (if any) into the matching CNI
+// capability args.
+func buildCNIRuntimeConf(podNetwork PodNetwork, netName string, attachment NetAttachment, ifName string) (*libcni.RuntimeConf, error) {
 	logrus.Infof("Got pod network %+v", podNetwork)
 
 	rt := &libcni.RuntimeConf{
@@ -507,13 +815,10 @@ func buildCNIRuntimeConf(podNetwork PodNetwork, ifName string) (*libcni.RuntimeC
 		},
 	}
 
-	if len(podNetwork.PortMappings) == 0 {
-		return rt, nil
+	if capabilityArgs := GetCapabilityArgs(podNetwork, netName, attachment); len(capabilityArgs) != 0 {
+		rt.CapabilityArgs = capabilityArgs
 	}
 
-	rt.CapabilityArgs = map[string]interface{}{
-		"portMappings": podNetwork.PortMappings,
-	}
 	return rt, nil
 }
 
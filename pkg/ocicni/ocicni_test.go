@@ -0,0 +1,173 @@
+package ocicni
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/containernetworking/cni/libcni"
+	cnitypes "github.com/containernetworking/cni/pkg/types"
+	cnicurrent "github.com/containernetworking/cni/pkg/types/current"
+)
+
+// fakeCNI is a libcni.CNI that records Add/Del calls and lets tests inject
+// an error from AddNetworkList, so SetUpPod's rollback path can be
+// exercised without touching real CNI plugin binaries.
+type fakeCNI struct {
+	addErr   error
+	addCalls int
+	delCalls int
+}
+
+func (f *fakeCNI) AddNetworkList(net *libcni.NetworkConfigList, rt *libcni.RuntimeConf) (cnitypes.Result, error) {
+	f.addCalls++
+	if f.addErr != nil {
+		return nil, f.addErr
+	}
+	return &cnicurrent.Result{CNIVersion: "0.3.1"}, nil
+}
+
+func (f *fakeCNI) CheckNetworkList(net *libcni.NetworkConfigList, rt *libcni.RuntimeConf) error {
+	return nil
+}
+
+func (f *fakeCNI) DelNetworkList(net *libcni.NetworkConfigList, rt *libcni.RuntimeConf) error {
+	f.delCalls++
+	return nil
+}
+
+func (f *fakeCNI) AddNetwork(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) (cnitypes.Result, error) {
+	return f.AddNetworkList(nil, rt)
+}
+
+func (f *fakeCNI) CheckNetwork(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) error {
+	return nil
+}
+
+func (f *fakeCNI) DelNetwork(net *libcni.NetworkConfig, rt *libcni.RuntimeConf) error {
+	return f.DelNetworkList(nil, rt)
+}
+
+// fakeNetwork builds a cniNetwork named name backed by cni, with a minimal
+// single-plugin config list.
+func fakeNetwork(name string, cni libcni.CNI) *cniNetwork {
+	confList, err := libcni.ConfListFromBytes([]byte(fmt.Sprintf(`{
+  "cniVersion": "0.3.1",
+  "name": %q,
+  "plugins": [{"type": "bridge"}]
+}`, name)))
+	if err != nil {
+		panic(err)
+	}
+	return &cniNetwork{name: name, NetworkConfig: confList, CNIConfig: cni}
+}
+
+func newTestPlugin(t *testing.T, networks map[string]*cniNetwork) *cniNetworkPlugin {
+	return &cniNetworkPlugin{
+		loNetwork:          fakeNetwork("lo", &fakeCNI{}),
+		networks:           networks,
+		defaultNetSelector: firstNetworkSelector,
+		cacheDir:           t.TempDir(),
+		workerLimit:        DefaultNetworkAttachmentWorkers,
+		pods:               make(map[string]*podLock),
+	}
+}
+
+func TestResolveAttachmentsGeneratesNonCollidingIfnames(t *testing.T) {
+	netA := fakeNetwork("netA", &fakeCNI{})
+	netB := fakeNetwork("netB", &fakeCNI{})
+	netC := fakeNetwork("netC", &fakeCNI{})
+	plugin := newTestPlugin(t, map[string]*cniNetwork{
+		"netA": netA, "netB": netB, "netC": netC,
+	})
+
+	podNetwork := PodNetwork{
+		Networks: []NetAttachment{
+			{Name: "netA", Ifname: "eth0"},
+			{Name: "netB"},
+			{Name: "netC"},
+		},
+	}
+
+	jobs, err := plugin.resolveAttachments(podNetwork)
+	if err != nil {
+		t.Fatalf("resolveAttachments returned error: %v", err)
+	}
+	if len(jobs) != 3 {
+		t.Fatalf("expected 3 jobs, got %d", len(jobs))
+	}
+
+	got := map[string]string{}
+	seen := map[string]bool{}
+	for _, job := range jobs {
+		got[job.network.name] = job.ifName
+		if seen[job.ifName] {
+			t.Fatalf("ifname %q assigned to more than one attachment", job.ifName)
+		}
+		seen[job.ifName] = true
+	}
+
+	if got["netA"] != "eth0" {
+		t.Errorf("netA: expected requested ifname eth0, got %q", got["netA"])
+	}
+	// netB and netC must each get a generated name, and neither may collide
+	// with the explicitly requested "eth0".
+	if got["netB"] == "eth0" || got["netC"] == "eth0" {
+		t.Errorf("generated ifname collided with explicitly requested eth0: netB=%q netC=%q", got["netB"], got["netC"])
+	}
+	if got["netB"] == got["netC"] {
+		t.Errorf("netB and netC got the same generated ifname %q", got["netB"])
+	}
+}
+
+func TestResolveAttachmentsDefaultsToDefaultNetwork(t *testing.T) {
+	def := fakeNetwork("default", &fakeCNI{})
+	plugin := newTestPlugin(t, map[string]*cniNetwork{"default": def})
+	plugin.defaultNetName = "default"
+
+	jobs, err := plugin.resolveAttachments(PodNetwork{})
+	if err != nil {
+		t.Fatalf("resolveAttachments returned error: %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].network.name != "default" || jobs[0].ifName != "eth0" {
+		t.Fatalf("expected single eth0 attachment to the default network, got %+v", jobs)
+	}
+}
+
+func TestSetUpPodRollsBackOnPartialFailure(t *testing.T) {
+	okCNI := &fakeCNI{}
+	failCNI := &fakeCNI{addErr: fmt.Errorf("plugin exec failed")}
+	netOK := fakeNetwork("netOK", okCNI)
+	netFail := fakeNetwork("netFail", failCNI)
+
+	plugin := newTestPlugin(t, map[string]*cniNetwork{
+		"netOK": netOK, "netFail": netFail,
+	})
+
+	podNetwork := PodNetwork{
+		ID:   "abc123",
+		Name: "testpod",
+		Networks: []NetAttachment{
+			{Name: "netOK", Ifname: "eth0"},
+			{Name: "netFail", Ifname: "eth1"},
+		},
+	}
+
+	_, err := plugin.SetUpPod(podNetwork)
+	if err == nil {
+		t.Fatal("expected SetUpPod to fail when one attachment's ADD fails")
+	}
+
+	if okCNI.addCalls != 1 {
+		t.Errorf("expected netOK ADD to be attempted once, got %d", okCNI.addCalls)
+	}
+	if okCNI.delCalls != 1 {
+		t.Errorf("expected netOK to be rolled back with a DEL, got %d DELs", okCNI.delCalls)
+	}
+	if failCNI.delCalls != 0 {
+		t.Errorf("netFail never succeeded, so it should not be rolled back, got %d DELs", failCNI.delCalls)
+	}
+
+	if _, err := plugin.cacheGet(podNetwork.ID, "netOK", "eth0"); err == nil {
+		t.Error("expected rolled-back attachment's cache entry to be removed")
+	}
+}
@@ -0,0 +1,184 @@
+package ocicni
+
+import (
+	cnitypes "github.com/containernetworking/cni/pkg/types"
+)
+
+const (
+	// CNIPluginName is the name of the plugin that this package implements
+	CNIPluginName = "cni"
+
+	DefaultNetDir = "/etc/cni/net.d"
+	DefaultCNIDir = "/opt/cni/bin"
+
+	VendorCNIDirTemplate = "%s/opt/%s/bin"
+
+	// DefaultNetworkAttachmentWorkers is how many of a pod's network
+	// attachments are set up or torn down concurrently when InitCNI is not
+	// given a WithWorkerLimit option.
+	DefaultNetworkAttachmentWorkers = 4
+)
+
+// CNIPlugin is the interface that needs to be implemented by a plugin
+type CNIPlugin interface {
+	// Name returns the plugin's name. This will be used when searching
+	// for a user-specified plugin by name
+	Name() string
+
+	// SetUpPod is the method called after the sandbox container of
+	// the pod has been created but before the other containers of the
+	// pod are created.
+	SetUpPod(network PodNetwork) ([]cnitypes.Result, error)
+
+	// TearDownPod is the method called before a pod's sandbox container
+	// will be deleted
+	TearDownPod(network PodNetwork) error
+
+	// GetPodNetworkStatus is the method called to obtain the ipv4 or
+	// ipv6 addresses of the pod sandbox
+	GetPodNetworkStatus(network PodNetwork) ([]cnitypes.Result, error)
+
+	// CheckPod verifies that a pod's existing network attachments are
+	// still correctly configured, via the CNI CHECK operation.
+	CheckPod(network PodNetwork) error
+
+	// GC tears down any cached network attachments that do not belong to
+	// one of activePods, cleaning up after pods whose TearDownPod was
+	// never called.
+	GC(activePods []PodNetwork) error
+
+	// NetworkList returns the names of all CNI networks currently loaded.
+	NetworkList() []string
+
+	// Status returns an error if the plugin is unable to serve setup/
+	// teardown requests against its configured network(s)
+	Status() error
+}
+
+// NetworkInfo describes a single CNI network loaded from disk, for use by
+// a DefaultNetworkSelector or by NetworkList().
+type NetworkInfo struct {
+	// Name is the CNI network's name
+	Name string
+
+	// Type is the CNI plugin type of the network's first plugin
+	Type string
+
+	// File is the path to the config file the network was loaded from
+	File string
+}
+
+// DefaultNetworkSelector picks, from the networks currently loaded (in
+// on-disk config file sort order), which one should be used as the default
+// network for pods that don't request a specific one. It is re-evaluated
+// every time the on-disk CNI config set changes. Returning "" means no
+// network currently qualifies as the default.
+type DefaultNetworkSelector func(networks []NetworkInfo) string
+
+// PortMapping maps to the standard CNI portmapping Capability
+// see: https://github.com/containernetworking/cni/blob/master/CONVENTIONS.md
+type PortMapping struct {
+	// HostPort is the port number on the host
+	HostPort int32
+	// ContainerPort is the port number inside the sandbox
+	ContainerPort int32
+	// Protocol is the protocol of the port mapping
+	Protocol string
+	// HostIP is the host ip to use
+	HostIP string
+}
+
+// PodNetwork configures the network of a pod sandbox.
+type PodNetwork struct {
+	// Name is the name of the pod
+	Name string
+
+	// Namespace is the namespace of the pod
+	Namespace string
+
+	// ID is the container ID of the pod's infra container
+	ID string
+
+	// NetNS is the path to the network namespace of the pod's infra container
+	NetNS string
+
+	// Networks is a list of CNI networks to attach the pod to. If this
+	// list is empty, the plugin's default network is used instead.
+	Networks []NetAttachment
+
+	// PortMappings is the port mapping of the sandbox
+	PortMappings []PortMapping
+
+	// Aliases is a per-network list of DNS aliases to request for the pod,
+	// keyed by CNI network name, and passed to the CNI plugin attached to
+	// that network via the "aliases" capability (e.g. for the dnsname
+	// plugin).
+	Aliases map[string][]string
+}
+
+// NetAttachment describes a single CNI network that a PodNetwork should be
+// attached to, along with settings specific to that attachment.
+type NetAttachment struct {
+	// Name is the name of the CNI network to attach to. If empty, the
+	// plugin's default network is used.
+	Name string
+
+	// Ifname is the requested name of the interface inside the pod's
+	// network namespace. If empty, ocicni generates one (eth0, eth1, ...)
+	// that does not collide with any other attachment's requested Ifname.
+	Ifname string
+
+	// IPs is an optional list of static IP addresses (with prefix, e.g.
+	// "192.168.1.5/24") to request for this attachment via the CNI "ips"
+	// capability.
+	IPs []string
+
+	// MAC is an optional static MAC address to request for this attachment
+	// via the CNI "mac" capability.
+	MAC string
+
+	// Bandwidth is an optional ingress/egress traffic shaping configuration
+	// to request for this attachment via the CNI "bandwidth" capability.
+	Bandwidth *BandwidthConfig
+
+	// DNS optionally overrides the pod-wide resolver configuration for
+	// this attachment, via the CNI "dns" capability.
+	DNS *cnitypes.DNS
+}
+
+// BandwidthConfig specifies ingress/egress traffic shaping for a network
+// attachment, translated into the CNI "bandwidth" capability. Rates and
+// bursts are both in bits per second.
+type BandwidthConfig struct {
+	IngressRate  uint64 `json:"ingressRate"`
+	IngressBurst uint64 `json:"ingressBurst"`
+	EgressRate   uint64 `json:"egressRate"`
+	EgressBurst  uint64 `json:"egressBurst"`
+}
+
+// GetCapabilityArgs builds the CNI capability arguments map (portMappings,
+// ips, mac, aliases, bandwidth, dns) for attaching podNetwork to netName via
+// attachment, so that external callers needing to build their own
+// libcni.RuntimeConf don't have to hand-marshal these maps themselves.
+func GetCapabilityArgs(podNetwork PodNetwork, netName string, attachment NetAttachment) map[string]interface{} {
+	capabilityArgs := map[string]interface{}{}
+	if len(podNetwork.PortMappings) != 0 {
+		capabilityArgs["portMappings"] = podNetwork.PortMappings
+	}
+	if len(attachment.IPs) != 0 {
+		capabilityArgs["ips"] = attachment.IPs
+	}
+	if attachment.MAC != "" {
+		capabilityArgs["mac"] = attachment.MAC
+	}
+	if aliases := podNetwork.Aliases[netName]; len(aliases) != 0 {
+		capabilityArgs["aliases"] = aliases
+	}
+	if attachment.Bandwidth != nil {
+		capabilityArgs["bandwidth"] = attachment.Bandwidth
+	}
+	if attachment.DNS != nil {
+		capabilityArgs["dns"] = attachment.DNS
+	}
+	return capabilityArgs
+}
@@ -49,10 +49,10 @@ func printSandboxResults(results []cnitypes.Result) {
 func main() {
 	networksStr := flag.String("networks", "", "comma-separated list of CNI network names (optional)")
 	flag.Parse()
-	networks := make([]string, 0)
+	networks := make([]ocicni.NetAttachment, 0)
 	for _, name := range strings.Split(*networksStr, ",") {
 		if len(name) > 0 {
-			networks = append(networks, name)
+			networks = append(networks, ocicni.NetAttachment{Name: name})
 		}
 	}
 
@@ -79,7 +79,7 @@ func main() {
 		bindir = DefaultBinDir
 	}
 
-	plugin, err := ocicni.InitCNI("", confdir, bindir)
+	plugin, err := ocicni.InitCNI("", confdir, []string{bindir})
 	if err != nil {
 		exit(err)
 	}